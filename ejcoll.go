@@ -4,7 +4,14 @@ package goejdb
 // #include <ejdb/ejdb.h>
 import "C"
 
-import "unsafe"
+import (
+    "encoding/json"
+    "strings"
+    "unsafe"
+
+    "github.com/mkilling/goejdb/qb"
+    "labix.org/v2/mgo/bson"
+)
 
 // Index modes, index types.
 const (
@@ -92,6 +99,49 @@ func (coll *EjColl) Find(query string, queries ...string) ([][]byte, *EjdbError)
     return q.Execute(coll)
 }
 
+// Execute a query specified by JSON strings query, queries and return a cursor over the results
+// instead of materializing them all at once. This is preferable to Find for large result sets.
+// Unlike EjQuery.Iterate, the returned cursor owns its query: Close deletes it, so callers must
+// not also call Del() on it themselves.
+// See the documentation of EjQuery for a description of the query format.
+func (coll *EjColl) FindIter(query string, queries ...string) (*EjCursor, *EjdbError) {
+    q, err := coll.ejdb.CreateQuery(query, queries...)
+    if err != nil {
+        return nil, err
+    }
+    cur, err := q.Iterate(coll)
+    if err != nil {
+        q.Del()
+        return nil, err
+    }
+    cur.ownsQuery = true
+    return cur, nil
+}
+
+// Execute a query built with the qb package and return the results as a
+// slice of BSON objects, applying any hints (OrderBy/Fields/Skip/Max) set on
+// q. See the qb package and Ejdb.CreateQuery for the supported operators.
+func (coll *EjColl) FindQ(q *qb.Query) ([][]byte, *EjdbError) {
+    eq, err := coll.ejdb.CreateQuery(q.Query())
+    if err != nil {
+        return nil, err
+    }
+    defer eq.Del()
+
+    if hints := q.Hints(); hints != "{}" {
+        if err := eq.SetHints(hints); err != nil {
+            return nil, err
+        }
+    }
+    return eq.Execute(coll)
+}
+
+// Execute an update built with the qb package, returning the number of
+// records updated. See the qb package for the supported update operators.
+func (coll *EjColl) UpdateQ(q *qb.Query) (int, *EjdbError) {
+    return coll.Update(q.Query())
+}
+
 // Execute a query specified by JSON strings query, queries and return only the first result as a BSON object
 // See the documentation of EjQuery  for a description of the query format.
 func (coll *EjColl) FindOne(query string, queries ...string) (*[]byte, *EjdbError) {
@@ -114,8 +164,175 @@ func (coll *EjColl) Count(query string, queries ...string) (int, *EjdbError) {
     return q.Count(coll)
 }
 
+// pushOp captures the $push/$pushAll values pending for a single field path,
+// extracted from an update query so they can be applied by updateWithPush.
+type pushOp struct {
+    values []interface{}
+}
+
+// pushUpdate splits an update query containing $push/$pushAll into the
+// pieces updateWithPush needs: the plain match criteria (to find the
+// affected records up front), any other update operators the query also
+// carries (applied via the native path, since the C library already
+// implements those correctly), and the per-field push operations themselves.
+type pushUpdate struct {
+    match    string // query with every top-level operator stripped, for Find
+    otherOps map[string]interface{} // operators other than $push/$pushAll, by name
+    pushOps  map[string]pushOp
+}
+
+// extractPushOps parses query as JSON and, if it carries a $push or
+// $pushAll clause, returns the pushUpdate built from it. ok is false when
+// query has neither clause (or fails to parse as an object), in which case
+// query should be passed through to the native update path unmodified.
+func extractPushOps(query string) (*pushUpdate, bool) {
+    var q map[string]interface{}
+    if err := json.Unmarshal([]byte(query), &q); err != nil {
+        return nil, false
+    }
+
+    pushOps := make(map[string]pushOp)
+    if push, has := q["$push"].(map[string]interface{}); has {
+        for fpath, v := range push {
+            pushOps[fpath] = pushOp{values: []interface{}{v}}
+        }
+        delete(q, "$push")
+    }
+    if pushAll, has := q["$pushAll"].(map[string]interface{}); has {
+        for fpath, v := range pushAll {
+            arr, _ := v.([]interface{})
+            op := pushOps[fpath]
+            op.values = append(op.values, arr...)
+            pushOps[fpath] = op
+        }
+        delete(q, "$pushAll")
+    }
+    if len(pushOps) == 0 {
+        return nil, false
+    }
+
+    match := make(map[string]interface{}, len(q))
+    otherOps := make(map[string]interface{})
+    for k, v := range q {
+        if strings.HasPrefix(k, "$") {
+            otherOps[k] = v
+            continue
+        }
+        match[k] = v
+    }
+    matchEncoded, err := json.Marshal(match)
+    if err != nil {
+        return nil, false
+    }
+
+    return &pushUpdate{match: string(matchEncoded), otherOps: otherOps, pushOps: pushOps}, true
+}
+
+// appendPushValues appends values to the array at the (possibly dotted)
+// field path within doc, creating intermediate objects and the array itself
+// as needed.
+func appendPushValues(doc bson.M, fpath string, values []interface{}) {
+    parts := strings.Split(fpath, ".")
+    m := doc
+    for _, p := range parts[:len(parts)-1] {
+        next, ok := m[p].(bson.M)
+        if !ok {
+            next = bson.M{}
+            m[p] = next
+        }
+        m = next
+    }
+    last := parts[len(parts)-1]
+    arr, _ := m[last].([]interface{})
+    m[last] = append(arr, values...)
+}
+
+// updateWithPush implements $push/$pushAll as a load-modify-save pass. It
+// first resolves pu.match to the exact set of oids to update, since running
+// pu.match again after pu.otherOps has already been applied natively could
+// silently match nothing if one of those operators rewrites a field the
+// match also filters on (e.g. a $set on the same field the query selects
+// by). If pu carries other update operators alongside the push clauses
+// (e.g. $set), they are then run natively, scoped to that captured oid set
+// via an explicit _id $in clause, since the C library already implements
+// them correctly. Finally each captured record is reloaded, the requested
+// values are appended to the target array fields, and the result is saved
+// back. This keeps $push and $pushAll working the same way regardless of
+// whether the linked libejdb knows about them natively, without silently
+// dropping any other operator the same query also carried or losing track
+// of which records pu.match originally selected.
+func (coll *EjColl) updateWithPush(pu *pushUpdate, queries ...string) (int, *EjdbError) {
+    rows, err := coll.Find(pu.match, queries...)
+    if err != nil {
+        return 0, err
+    }
+    oids := make([]string, 0, len(rows))
+    for _, row := range rows {
+        var doc bson.M
+        if bson.Unmarshal(row, &doc) != nil {
+            continue
+        }
+        oid, ok := doc["_id"].(bson.ObjectId)
+        if !ok {
+            continue
+        }
+        oids = append(oids, oid.Hex())
+    }
+    if len(oids) == 0 {
+        return 0, nil
+    }
+
+    if len(pu.otherOps) > 0 {
+        scoped := make(map[string]interface{}, len(pu.otherOps)+1)
+        for k, v := range pu.otherOps {
+            scoped[k] = v
+        }
+        scoped["_id"] = map[string]interface{}{"$in": oids}
+        scopedEncoded, merr := json.Marshal(scoped)
+        if merr != nil {
+            return 0, coll.ejdb.check_error()
+        }
+        if _, err := coll.nativeUpdate(string(scopedEncoded), queries...); err != nil {
+            return 0, err
+        }
+    }
+
+    count := 0
+    for _, oid := range oids {
+        row := coll.LoadBson(oid)
+        if row == nil {
+            continue
+        }
+        var doc bson.M
+        if bson.Unmarshal(row, &doc) != nil {
+            continue
+        }
+        for fpath, op := range pu.pushOps {
+            appendPushValues(doc, fpath, op.values)
+        }
+        data, merr := bson.Marshal(doc)
+        if merr != nil {
+            continue
+        }
+        if _, serr := coll.SaveBson(data); serr != nil {
+            return count, serr
+        }
+        count++
+    }
+    return count, nil
+}
+
 // EJDB_EXPORT uint32_t ejdbupdate(EJCOLL *jcoll, bson *qobj, bson *orqobjs, int orqobjsnum, bson *hints, TCXSTR *log);
 func (coll *EjColl) Update(query string, queries ...string) (int, *EjdbError) {
+    if pu, ok := extractPushOps(query); ok {
+        return coll.updateWithPush(pu, queries...)
+    }
+    return coll.nativeUpdate(query, queries...)
+}
+
+// nativeUpdate runs ejdbupdate directly, with no client-side operator
+// rewriting. Update and updateWithPush both funnel through it.
+func (coll *EjColl) nativeUpdate(query string, queries ...string) (int, *EjdbError) {
     query_bson := bson_from_json(query)
     defer C.bson_destroy(query_bson)
 
@@ -132,6 +349,135 @@ func (coll *EjColl) Update(query string, queries ...string) (int, *EjdbError) {
     return int(count), coll.ejdb.check_error()
 }
 
+// fieldAt resolves the (possibly dotted) field path fpath within doc,
+// drilling into nested bson.M values the same way appendPushValues does.
+func fieldAt(doc bson.M, fpath string) interface{} {
+    m := doc
+    parts := strings.Split(fpath, ".")
+    for _, p := range parts[:len(parts)-1] {
+        next, ok := m[p].(bson.M)
+        if !ok {
+            return nil
+        }
+        m = next
+    }
+    return m[parts[len(parts)-1]]
+}
+
+// extractOids returns the string OID(s) referenced at fpath within doc,
+// which per the $do/$join query form may be a single OID, its string
+// representation, or an array of either. fpath may be dotted to reach a
+// nested field, e.g. "owner.id".
+func extractOids(doc bson.M, fpath string) []string {
+    switch val := fieldAt(doc, fpath).(type) {
+    case bson.ObjectId:
+        return []string{val.Hex()}
+    case string:
+        return []string{val}
+    case []interface{}:
+        oids := make([]string, 0, len(val))
+        for _, el := range val {
+            switch e := el.(type) {
+            case bson.ObjectId:
+                oids = append(oids, e.Hex())
+            case string:
+                oids = append(oids, e)
+            }
+        }
+        return oids
+    }
+    return nil
+}
+
+// FindJoin executes query on coll and, for every OID field path named in
+// joins, resolves the referenced records from the given target collection.
+// Unlike the server-side `$do : {fpath : {$join : 'collectionname'}}` form,
+// which looks child records up one row at a time, FindJoin collects the
+// distinct OIDs per join path across the whole result set and issues a
+// single `{"_id":{"$in":[...]}}` query per target collection.
+//
+// Each returned map holds the parent row's BSON under the "" key, plus the
+// resolved child BSON for every join path that matched, keyed by that path.
+// Where a join path holds an array of OIDs, only one child is kept under
+// that path: the one for the last OID in the array that resolved.
+func (coll *EjColl) FindJoin(query string, joins map[string]string) ([]map[string][]byte, *EjdbError) {
+    rows, err := coll.Find(query)
+    if err != nil {
+        return nil, err
+    }
+
+    docs := make([]bson.M, len(rows))
+    oidsByPath := make(map[string]map[string]bool, len(joins))
+    for i, row := range rows {
+        var doc bson.M
+        if bson.Unmarshal(row, &doc) != nil {
+            continue
+        }
+        docs[i] = doc
+        for fpath := range joins {
+            for _, oid := range extractOids(doc, fpath) {
+                set, ok := oidsByPath[fpath]
+                if !ok {
+                    set = make(map[string]bool)
+                    oidsByPath[fpath] = set
+                }
+                set[oid] = true
+            }
+        }
+    }
+
+    childrenByPath := make(map[string]map[string][]byte, len(joins))
+    for fpath, collname := range joins {
+        oids := oidsByPath[fpath]
+        if len(oids) == 0 {
+            continue
+        }
+        target, terr := coll.ejdb.GetColl(collname)
+        if terr != nil {
+            return nil, terr
+        }
+
+        idlist := make([]string, 0, len(oids))
+        for oid := range oids {
+            idlist = append(idlist, oid)
+        }
+        inq, merr := json.Marshal(map[string]interface{}{"_id": map[string]interface{}{"$in": idlist}})
+        if merr != nil {
+            continue
+        }
+
+        matches, ferr := target.Find(string(inq))
+        if ferr != nil {
+            return nil, ferr
+        }
+        byOid := make(map[string][]byte, len(matches))
+        for _, child := range matches {
+            var cdoc bson.M
+            if bson.Unmarshal(child, &cdoc) != nil {
+                continue
+            }
+            if oid, ok := cdoc["_id"].(bson.ObjectId); ok {
+                byOid[oid.Hex()] = child
+            }
+        }
+        childrenByPath[fpath] = byOid
+    }
+
+    result := make([]map[string][]byte, len(rows))
+    for i, row := range rows {
+        out := map[string][]byte{"": row}
+        for fpath, byOid := range childrenByPath {
+            for _, oid := range extractOids(docs[i], fpath) {
+                if child, ok := byOid[oid]; ok {
+                    out[fpath] = child
+                }
+            }
+        }
+        result[i] = out
+    }
+    return result, nil
+}
+
 // Set index for JSON field in EJDB collection.
 //
 //  - Available index types:
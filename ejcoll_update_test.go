@@ -0,0 +1,147 @@
+package goejdb
+
+import (
+    "os"
+    "strings"
+    "testing"
+)
+
+const pushTestDbPath = "/tmp/goejdb_push_test.db"
+
+func openPushTestColl(t *testing.T) (*Ejdb, *EjColl) {
+    os.Remove(pushTestDbPath)
+    ejdb, err := Open(pushTestDbPath, JBOWRITER|JBOCREAT|JBOTRUNC)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    coll, err := ejdb.CreateColl("push_test", nil)
+    if err != nil {
+        ejdb.Close()
+        t.Fatalf("CreateColl: %v", err)
+    }
+    return ejdb, coll
+}
+
+func TestUpdatePush(t *testing.T) {
+    ejdb, coll := openPushTestColl(t)
+    defer os.Remove(pushTestDbPath)
+    defer ejdb.Close()
+
+    oid, err := coll.SaveJson(`{"name":"Bruce","tags":["a"]}`)
+    if err != nil {
+        t.Fatalf("SaveJson: %v", err)
+    }
+
+    n, err := coll.Update(`{"_id":"` + oid + `", "$push":{"tags":"b"}}`)
+    if err != nil {
+        t.Fatalf("Update $push: %v", err)
+    }
+    if n != 1 {
+        t.Fatalf("expected 1 record updated, got %d", n)
+    }
+
+    row, err := coll.FindOne(`{"_id":"` + oid + `"}`)
+    if err != nil {
+        t.Fatalf("FindOne: %v", err)
+    }
+    if row == nil || !strings.Contains(string(*row), "b") {
+        t.Fatalf("expected tags to contain the pushed value, got %v", row)
+    }
+}
+
+func TestUpdatePushAll(t *testing.T) {
+    ejdb, coll := openPushTestColl(t)
+    defer os.Remove(pushTestDbPath)
+    defer ejdb.Close()
+
+    oid, err := coll.SaveJson(`{"name":"Bruce","tags":["a"]}`)
+    if err != nil {
+        t.Fatalf("SaveJson: %v", err)
+    }
+
+    n, err := coll.Update(`{"_id":"` + oid + `", "$pushAll":{"tags":["b","c"]}}`)
+    if err != nil {
+        t.Fatalf("Update $pushAll: %v", err)
+    }
+    if n != 1 {
+        t.Fatalf("expected 1 record updated, got %d", n)
+    }
+
+    row, err := coll.FindOne(`{"_id":"` + oid + `"}`)
+    if err != nil {
+        t.Fatalf("FindOne: %v", err)
+    }
+    if row == nil || !strings.Contains(string(*row), "b") || !strings.Contains(string(*row), "c") {
+        t.Fatalf("expected tags to contain both pushed values, got %v", row)
+    }
+}
+
+// TestUpdatePushWithOtherOperators guards against $push/$pushAll silently
+// swallowing any other operator the same update carries: both must apply.
+func TestUpdatePushWithOtherOperators(t *testing.T) {
+    ejdb, coll := openPushTestColl(t)
+    defer os.Remove(pushTestDbPath)
+    defer ejdb.Close()
+
+    oid, err := coll.SaveJson(`{"name":"Bruce","tags":["a"]}`)
+    if err != nil {
+        t.Fatalf("SaveJson: %v", err)
+    }
+
+    n, err := coll.Update(`{"_id":"` + oid + `", "$push":{"tags":"x"}, "$set":{"name":"Wayne"}}`)
+    if err != nil {
+        t.Fatalf("Update $push+$set: %v", err)
+    }
+    if n != 1 {
+        t.Fatalf("expected 1 record updated, got %d", n)
+    }
+
+    row, err := coll.FindOne(`{"_id":"` + oid + `"}`)
+    if err != nil {
+        t.Fatalf("FindOne: %v", err)
+    }
+    if row == nil {
+        t.Fatal("expected a record to be found")
+    }
+    got := string(*row)
+    if !strings.Contains(got, "x") {
+        t.Errorf("expected $push to have applied, got %v", got)
+    }
+    if !strings.Contains(got, "Wayne") {
+        t.Errorf("expected $set to also have applied, got %v", got)
+    }
+}
+
+// TestUpdatePushWithOtherOperatorsRewritingMatchField guards against the
+// predicate used to find the affected records being re-applied after $set
+// has already rewritten the very field it filters on, which would make the
+// record unfindable by the time $push runs.
+func TestUpdatePushWithOtherOperatorsRewritingMatchField(t *testing.T) {
+    ejdb, coll := openPushTestColl(t)
+    defer os.Remove(pushTestDbPath)
+    defer ejdb.Close()
+
+    if _, err := coll.SaveJson(`{"name":"Bruce","tags":["a"]}`); err != nil {
+        t.Fatalf("SaveJson: %v", err)
+    }
+
+    n, err := coll.Update(`{"name":"Bruce", "$push":{"tags":"x"}, "$set":{"name":"Wayne"}}`)
+    if err != nil {
+        t.Fatalf("Update $push+$set: %v", err)
+    }
+    if n != 1 {
+        t.Fatalf("expected 1 record updated, got %d", n)
+    }
+
+    row, err := coll.FindOne(`{"name":"Wayne"}`)
+    if err != nil {
+        t.Fatalf("FindOne: %v", err)
+    }
+    if row == nil {
+        t.Fatal("expected a record to be found under its new name")
+    }
+    got := string(*row)
+    if !strings.Contains(got, "x") {
+        t.Errorf("expected $push to have applied despite $set rewriting the match field, got %v", got)
+    }
+}
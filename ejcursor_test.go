@@ -0,0 +1,78 @@
+package goejdb
+
+import (
+    "os"
+    "testing"
+)
+
+const cursorTestDbPath = "/tmp/goejdb_cursor_test.db"
+
+func openCursorTestColl(t *testing.T) (*Ejdb, *EjColl) {
+    os.Remove(cursorTestDbPath)
+    ejdb, err := Open(cursorTestDbPath, JBOWRITER|JBOCREAT|JBOTRUNC)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    coll, err := ejdb.CreateColl("cursor_test", nil)
+    if err != nil {
+        ejdb.Close()
+        t.Fatalf("CreateColl: %v", err)
+    }
+    return ejdb, coll
+}
+
+// TestFindIterDrainsAllResults guards against FindIter's cursor dropping or
+// duplicating rows, and against Next returning ok once the cursor is
+// exhausted.
+func TestFindIterDrainsAllResults(t *testing.T) {
+    ejdb, coll := openCursorTestColl(t)
+    defer os.Remove(cursorTestDbPath)
+    defer ejdb.Close()
+
+    for i := 0; i < 3; i++ {
+        if _, err := coll.SaveJson(`{"n":` + string(rune('0'+i)) + `}`); err != nil {
+            t.Fatalf("SaveJson: %v", err)
+        }
+    }
+
+    cur, err := coll.FindIter(`{}`)
+    if err != nil {
+        t.Fatalf("FindIter: %v", err)
+    }
+    defer cur.Close()
+
+    count := 0
+    for {
+        _, ok := cur.Next()
+        if !ok {
+            break
+        }
+        count++
+    }
+    if count != 3 {
+        t.Fatalf("expected 3 rows, got %d", count)
+    }
+    if _, ok := cur.Next(); ok {
+        t.Fatal("expected Next to report exhausted after draining the cursor")
+    }
+}
+
+// TestFindIterCloseOwnsQuery guards against Close on a FindIter cursor, which
+// also deletes the query it owns, panicking or double freeing when called
+// more than once.
+func TestFindIterCloseOwnsQuery(t *testing.T) {
+    ejdb, coll := openCursorTestColl(t)
+    defer os.Remove(cursorTestDbPath)
+    defer ejdb.Close()
+
+    if _, err := coll.SaveJson(`{"n":1}`); err != nil {
+        t.Fatalf("SaveJson: %v", err)
+    }
+
+    cur, err := coll.FindIter(`{}`)
+    if err != nil {
+        t.Fatalf("FindIter: %v", err)
+    }
+    cur.Close()
+    cur.Close()
+}
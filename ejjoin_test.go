@@ -0,0 +1,54 @@
+package goejdb
+
+import (
+    "os"
+    "testing"
+)
+
+const joinTestDbPath = "/tmp/goejdb_join_test.db"
+
+func openJoinTestDb(t *testing.T) *Ejdb {
+    os.Remove(joinTestDbPath)
+    ejdb, err := Open(joinTestDbPath, JBOWRITER|JBOCREAT|JBOTRUNC)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    return ejdb
+}
+
+// TestFindJoinNestedFieldPath guards against a join path like "owner.id"
+// silently resolving nothing: fpath must drill into nested documents the
+// same way the rest of the package's field paths do.
+func TestFindJoinNestedFieldPath(t *testing.T) {
+    ejdb := openJoinTestDb(t)
+    defer os.Remove(joinTestDbPath)
+    defer ejdb.Close()
+
+    users, err := ejdb.CreateColl("join_users", nil)
+    if err != nil {
+        t.Fatalf("CreateColl join_users: %v", err)
+    }
+    accounts, err := ejdb.CreateColl("join_accounts", nil)
+    if err != nil {
+        t.Fatalf("CreateColl join_accounts: %v", err)
+    }
+
+    userOid, err := users.SaveJson(`{"name":"Bruce"}`)
+    if err != nil {
+        t.Fatalf("SaveJson user: %v", err)
+    }
+    if _, err := accounts.SaveJson(`{"owner":{"id":"` + userOid + `"},"balance":100}`); err != nil {
+        t.Fatalf("SaveJson account: %v", err)
+    }
+
+    rows, err := accounts.FindJoin(`{}`, map[string]string{"owner.id": "join_users"})
+    if err != nil {
+        t.Fatalf("FindJoin: %v", err)
+    }
+    if len(rows) != 1 {
+        t.Fatalf("expected 1 row, got %d", len(rows))
+    }
+    if child, ok := rows[0]["owner.id"]; !ok || child == nil {
+        t.Fatalf("expected resolved child for nested join path \"owner.id\", got %v", rows[0])
+    }
+}
@@ -0,0 +1,326 @@
+package goejdb
+
+import (
+    "fmt"
+    "strconv"
+
+    "labix.org/v2/mgo/bson"
+)
+
+// DefaultGroupCap bounds, by default, how many distinct keys MapReduce
+// accumulates fully in memory before spilling the coldest groups' current
+// reduced value out to a temporary collection, keeping memory use bounded
+// regardless of how many distinct keys mapFn emits. Override it via
+// EjColl.MapReduceWithCap.
+const DefaultGroupCap = 10000
+
+// mrSpillColl names the temporary collection MapReduce spills overflow
+// groups into. It is safe to share across calls since every record written
+// to it is removed again once that group has been merged back in.
+const mrSpillColl = "_goejdb_mr_spill"
+
+// MapReduce iterates the results of query through a streaming cursor (see
+// EjColl.FindIter), invoking mapFn per document to emit zero or more (key,
+// value) pairs, then folds the values collected for each key through
+// reduceFn. reduceFn must be associative: once more than DefaultGroupCap
+// distinct keys are live at once it is also applied to partially-reduced
+// values rather than only raw mapFn output, mirroring the combiner step of
+// the mongo mr.cpp model this is inspired by. See MapReduceWithCap to
+// override the in-memory group cap.
+func (coll *EjColl) MapReduce(query string, mapFn func(doc []byte, emit func(key string, value []byte)), reduceFn func(key string, values [][]byte) []byte) (map[string][]byte, *EjdbError) {
+    return coll.MapReduceWithCap(query, mapFn, reduceFn, DefaultGroupCap)
+}
+
+// MapReduceWithCap behaves like MapReduce, but spills the coldest groups'
+// current reduced value to a temporary collection once more than groupCap
+// distinct keys are held in memory at once. Spilled groups are merged back
+// in, and their temporary records removed, before MapReduceWithCap returns.
+func (coll *EjColl) MapReduceWithCap(query string, mapFn func(doc []byte, emit func(key string, value []byte)), reduceFn func(key string, values [][]byte) []byte, groupCap int) (map[string][]byte, *EjdbError) {
+    cur, err := coll.FindIter(query)
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close()
+
+    spill, serr := coll.ejdb.CreateColl(mrSpillColl, nil)
+    if serr != nil {
+        return nil, serr
+    }
+
+    groups := make(map[string][][]byte)
+    order := make([]string, 0)
+    spilled := make(map[string]string) // key -> oid of its reduced value in spill
+
+    emit := func(key string, value []byte) {
+        if _, ok := groups[key]; !ok {
+            order = append(order, key)
+        }
+        groups[key] = append(groups[key], value)
+    }
+
+    for {
+        doc, ok := cur.Next()
+        if !ok {
+            break
+        }
+        mapFn(doc, emit)
+
+        for len(groups) > groupCap && len(order) > 0 {
+            key := order[0]
+            order = order[1:]
+            values, ok := groups[key]
+            if !ok {
+                continue
+            }
+            delete(groups, key)
+
+            reduced := reduceFn(key, values)
+            if prevOid, already := spilled[key]; already {
+                if prev, ok := loadSpilled(spill, prevOid); ok {
+                    reduced = reduceFn(key, [][]byte{prev, reduced})
+                }
+                spill.RmBson(prevOid)
+            }
+            data, merr := bson.Marshal(bson.M{"v": reduced})
+            if merr != nil {
+                // bson.Marshal can't realistically fail on a []byte payload;
+                // keep the group in memory rather than lose it.
+                groups[key] = values
+                order = append(order, key)
+                continue
+            }
+            oid, serr := spill.SaveBson(data)
+            if serr != nil {
+                return nil, serr
+            }
+            spilled[key] = oid
+        }
+    }
+
+    result := make(map[string][]byte, len(groups)+len(spilled))
+    for key, values := range groups {
+        result[key] = reduceFn(key, values)
+    }
+    for key, oid := range spilled {
+        v, ok := loadSpilled(spill, oid)
+        spill.RmBson(oid)
+        if !ok {
+            continue
+        }
+        if existing, inMem := result[key]; inMem {
+            result[key] = reduceFn(key, [][]byte{existing, v})
+        } else {
+            result[key] = v
+        }
+    }
+    return result, nil
+}
+
+// loadSpilled loads the reduced value saved at oid in spill and unwraps it
+// back to the raw bytes reduceFn expects, undoing the bson.M{"v": ...}
+// wrapping SaveBson requires a document rather than a bare byte slice.
+func loadSpilled(spill *EjColl, oid string) ([]byte, bool) {
+    var wrap bson.M
+    if bson.Unmarshal(spill.LoadBson(oid), &wrap) != nil {
+        return nil, false
+    }
+    v, ok := wrap["v"].([]byte)
+    return v, ok
+}
+
+// AggKind selects one of GroupBy's prebuilt aggregators.
+type AggKind int
+
+const (
+    AggCount AggKind = iota
+    AggSum
+    AggAvg
+    AggMin
+    AggMax
+    AggPush
+)
+
+// AggSpec configures GroupBy's aggregation. Field names the document field
+// to aggregate and is ignored for AggCount.
+type AggSpec struct {
+    Kind  AggKind
+    Field string
+}
+
+// GroupResult is one row of GroupBy's output: the distinct group key and
+// its aggregated Value, which is a float64 for AggCount/AggSum/AggAvg/
+// AggMin/AggMax and a []interface{} of the grouped documents for AggPush.
+type GroupResult struct {
+    Key   string
+    Value interface{}
+}
+
+// toFloat64 coerces a decoded bson numeric field to float64.
+func toFloat64(v interface{}) (float64, bool) {
+    switch n := v.(type) {
+    case float64:
+        return n, true
+    case float32:
+        return float64(n), true
+    case int:
+        return float64(n), true
+    case int32:
+        return float64(n), true
+    case int64:
+        return float64(n), true
+    }
+    return 0, false
+}
+
+func encodeFloat(f float64) []byte {
+    return []byte(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func decodeFloat(b []byte) float64 {
+    f, _ := strconv.ParseFloat(string(b), 64)
+    return f
+}
+
+func encodeAvgPartial(sum, count float64) []byte {
+    data, _ := bson.Marshal(bson.M{"s": sum, "c": count})
+    return data
+}
+
+func decodeAvgPartial(b []byte) (sum, count float64) {
+    var m bson.M
+    if bson.Unmarshal(b, &m) != nil {
+        return 0, 0
+    }
+    sum, _ = toFloat64(m["s"])
+    count, _ = toFloat64(m["c"])
+    return sum, count
+}
+
+// aggMapValue encodes the per-document partial aggregate for agg, in the
+// same shape aggReduce combines, so reduced and raw values can be mixed
+// freely once MapReduce starts spilling.
+func aggMapValue(agg AggSpec, doc bson.M) ([]byte, bool) {
+    switch agg.Kind {
+    case AggCount:
+        return encodeFloat(1), true
+    case AggSum, AggMin, AggMax:
+        n, ok := toFloat64(doc[agg.Field])
+        if !ok {
+            return nil, false
+        }
+        return encodeFloat(n), true
+    case AggAvg:
+        n, ok := toFloat64(doc[agg.Field])
+        if !ok {
+            return nil, false
+        }
+        return encodeAvgPartial(n, 1), true
+    case AggPush:
+        data, err := bson.Marshal(bson.M{"v": []interface{}{doc}})
+        if err != nil {
+            return nil, false
+        }
+        return data, true
+    }
+    return nil, false
+}
+
+// aggReduce combines partial aggregates of the shape aggMapValue produces.
+// It must be associative, since MapReduceWithCap may call it again on a mix
+// of already-combined and fresh partial aggregates.
+func aggReduce(agg AggSpec, values [][]byte) []byte {
+    switch agg.Kind {
+    case AggCount, AggSum:
+        var total float64
+        for _, v := range values {
+            total += decodeFloat(v)
+        }
+        return encodeFloat(total)
+    case AggMin, AggMax:
+        var result float64
+        first := true
+        for _, v := range values {
+            n := decodeFloat(v)
+            if first || (agg.Kind == AggMin && n < result) || (agg.Kind == AggMax && n > result) {
+                result = n
+            }
+            first = false
+        }
+        return encodeFloat(result)
+    case AggAvg:
+        var sum, count float64
+        for _, v := range values {
+            s, c := decodeAvgPartial(v)
+            sum += s
+            count += c
+        }
+        return encodeAvgPartial(sum, count)
+    case AggPush:
+        arr := make([]interface{}, 0, len(values))
+        for _, v := range values {
+            var wrap bson.M
+            if bson.Unmarshal(v, &wrap) != nil {
+                continue
+            }
+            if elems, ok := wrap["v"].([]interface{}); ok {
+                arr = append(arr, elems...)
+            }
+        }
+        data, _ := bson.Marshal(bson.M{"v": arr})
+        return data
+    }
+    return nil
+}
+
+// decodeAggResult turns a fully-reduced partial aggregate into the value
+// GroupBy surfaces to callers.
+func decodeAggResult(kind AggKind, value []byte) interface{} {
+    switch kind {
+    case AggCount, AggSum, AggMin, AggMax:
+        return decodeFloat(value)
+    case AggAvg:
+        sum, count := decodeAvgPartial(value)
+        if count == 0 {
+            return 0.0
+        }
+        return sum / count
+    case AggPush:
+        var wrap bson.M
+        if bson.Unmarshal(value, &wrap) != nil {
+            return nil
+        }
+        return wrap["v"]
+    }
+    return nil
+}
+
+// GroupBy groups every document in coll by the value of fieldPath and
+// aggregates each group with agg. It is built on top of MapReduce, so large
+// numbers of distinct groups are handled with the same bounded memory use.
+func (coll *EjColl) GroupBy(fieldPath string, agg AggSpec) ([]GroupResult, *EjdbError) {
+    mapFn := func(doc []byte, emit func(key string, value []byte)) {
+        var d bson.M
+        if bson.Unmarshal(doc, &d) != nil {
+            return
+        }
+        value, ok := aggMapValue(agg, d)
+        if !ok {
+            return
+        }
+        emit(fmt.Sprintf("%v", d[fieldPath]), value)
+    }
+    reduceFn := func(key string, values [][]byte) []byte {
+        return aggReduce(agg, values)
+    }
+
+    reduced, err := coll.MapReduce("{}", mapFn, reduceFn)
+    if err != nil {
+        return nil, err
+    }
+
+    results := make([]GroupResult, 0, len(reduced))
+    for key, value := range reduced {
+        results = append(results, GroupResult{Key: key, Value: decodeAggResult(agg.Kind, value)})
+    }
+    return results, nil
+}
@@ -0,0 +1,116 @@
+package goejdb
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "testing"
+
+    "labix.org/v2/mgo/bson"
+)
+
+const mrTestDbPath = "/tmp/goejdb_mr_test.db"
+
+func openMRTestColl(t *testing.T) (*Ejdb, *EjColl) {
+    os.Remove(mrTestDbPath)
+    ejdb, err := Open(mrTestDbPath, JBOWRITER|JBOCREAT|JBOTRUNC)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    coll, err := ejdb.CreateColl("mr_test", nil)
+    if err != nil {
+        ejdb.Close()
+        t.Fatalf("CreateColl: %v", err)
+    }
+    return ejdb, coll
+}
+
+// TestMapReduceWithCapSpillsAndMerges pins groupCap down to 1, forcing every
+// group to be spilled and re-spilled repeatedly, so a previously-spilled
+// partial that gets silently dropped on the next eviction shows up as an
+// undercounted sum rather than the true per-key total.
+func TestMapReduceWithCapSpillsAndMerges(t *testing.T) {
+    ejdb, coll := openMRTestColl(t)
+    defer os.Remove(mrTestDbPath)
+    defer ejdb.Close()
+
+    keys := []string{"k0", "k1", "k2", "k3", "k4"}
+    // Interleave the keys so each one is emitted again after being spilled.
+    for j := 0; j < 4; j++ {
+        for i, k := range keys {
+            v := 6 + i + j
+            if _, err := coll.SaveJson(fmt.Sprintf(`{"k":"%s","v":%d}`, k, v)); err != nil {
+                t.Fatalf("SaveJson: %v", err)
+            }
+        }
+    }
+
+    mapFn := func(doc []byte, emit func(key string, value []byte)) {
+        var d bson.M
+        if bson.Unmarshal(doc, &d) != nil {
+            return
+        }
+        k, _ := d["k"].(string)
+        n, _ := toFloat64(d["v"])
+        emit(k, []byte(strconv.FormatFloat(n, 'g', -1, 64)))
+    }
+    reduceFn := func(key string, values [][]byte) []byte {
+        var sum float64
+        for _, v := range values {
+            f, _ := strconv.ParseFloat(string(v), 64)
+            sum += f
+        }
+        return []byte(strconv.FormatFloat(sum, 'g', -1, 64))
+    }
+
+    result, err := coll.MapReduceWithCap(`{}`, mapFn, reduceFn, 1)
+    if err != nil {
+        t.Fatalf("MapReduceWithCap: %v", err)
+    }
+
+    want := map[string]float64{"k0": 30, "k1": 34, "k2": 38, "k3": 42, "k4": 46}
+    if len(result) != len(want) {
+        t.Fatalf("expected %d groups, got %d: %v", len(want), len(result), result)
+    }
+    for k, exp := range want {
+        got, ok := result[k]
+        if !ok {
+            t.Fatalf("missing key %q in result", k)
+        }
+        f, _ := strconv.ParseFloat(string(got), 64)
+        if f != exp {
+            t.Errorf("key %q: expected sum %v, got %v", k, exp, f)
+        }
+    }
+}
+
+func TestGroupBy(t *testing.T) {
+    ejdb, coll := openMRTestColl(t)
+    defer os.Remove(mrTestDbPath)
+    defer ejdb.Close()
+
+    docs := []string{
+        `{"k":"a","v":10}`,
+        `{"k":"a","v":20}`,
+        `{"k":"b","v":5}`,
+    }
+    for _, d := range docs {
+        if _, err := coll.SaveJson(d); err != nil {
+            t.Fatalf("SaveJson: %v", err)
+        }
+    }
+
+    results, err := coll.GroupBy("k", AggSpec{Kind: AggSum, Field: "v"})
+    if err != nil {
+        t.Fatalf("GroupBy: %v", err)
+    }
+
+    sums := make(map[string]float64, len(results))
+    for _, r := range results {
+        f, _ := r.Value.(float64)
+        sums[r.Key] = f
+    }
+    if sums["a"] != 30 || sums["b"] != 5 {
+        t.Fatalf("unexpected sums: %v", sums)
+    }
+}
@@ -76,6 +76,14 @@ type EjQuery struct {
 //          - {.., '$pull' : {'fpath' : val1, 'fpathN' : valN, ...}}
 //      $pullAll Batch version of $pull
 //          - {.., '$pullAll' : {'fpath' : [array of values to remove], ...}}
+//      $push Appends value to field, creating field as an array if missing.
+//          - {.., '$push' : {'fpath' : val1, 'fpathN' : valN, ...}}
+//      $pushAll Batch version of $push
+//          - {.., '$pushAll' : {'fpath' : [array of values to add], ...}}
+//
+//      NOTE: EjColl.Update applies $push/$pushAll as a client-side load-modify-save
+//      pass, so they behave consistently even against libejdb versions that
+//      don't understand these operators natively.
 //
 // - Collection joins supported in the following form:
 //
@@ -187,6 +195,71 @@ func (q *EjQuery) ExecuteOne(coll *EjColl) (*[]byte, *EjdbError) {
     return &ret, err
 }
 
+// An EJDB query result cursor. Unlike Execute, which materializes every
+// matching BSON document into a [][]byte up front, a cursor copies only the
+// current record into Go memory as it is consumed, which avoids the memory
+// spike Execute causes on large result sets.
+type EjCursor struct {
+    res       *C.TCLIST
+    q         *EjQuery
+    ownsQuery bool
+    count     int
+    pos       int
+    closed    bool
+}
+
+// Execute the query and return a cursor over the results instead of
+// materializing them all at once.
+//
+// Like Execute, Iterate does not take ownership of q: the cursor's Close
+// only disposes of the query result, so callers must still call q.Del()
+// themselves (typically via defer, right after CreateQuery). Use
+// EjColl.FindIter for a cursor whose Close also deletes its query.
+func (q *EjQuery) Iterate(coll *EjColl) (*EjCursor, *EjdbError) {
+    var count C.uint32_t
+    res := C.ejdbqryexecute((*C.struct_EJCOLL)(unsafe.Pointer(coll.ptr)), (*C.struct_EJQ)(unsafe.Pointer(q.ptr)), &count, 0, nil)
+    if err := coll.ejdb.check_error(); err != nil {
+        C.ejdbqresultdispose(res)
+        return nil, err
+    }
+    return &EjCursor{res: res, q: q, count: int(count)}, nil
+}
+
+// Next returns the next result's BSON data and true, or nil and false once
+// the cursor is exhausted.
+func (c *EjCursor) Next() ([]byte, bool) {
+    if c.closed || c.pos >= c.count {
+        return nil, false
+    }
+    var size C.int
+    bson_blob := C.ejdbqresultbsondata(c.res, C.int(c.pos), &size)
+    el := make([]byte, int(size))
+    copy(el, (*[maxslice]byte)(bson_blob)[:int(size)])
+    c.pos++
+    return el, true
+}
+
+// Err returns the error, if any, encountered while draining the cursor.
+// Once a cursor has been created successfully there is nothing left that can
+// fail, so this always returns nil; it exists for symmetry with Iterate.
+func (c *EjCursor) Err() *EjdbError {
+    return nil
+}
+
+// Close releases the underlying query result, and also deletes the query it
+// was created from if the cursor owns it (see EjColl.FindIter). It is safe
+// to call Close more than once.
+func (c *EjCursor) Close() {
+    if c.closed {
+        return
+    }
+    c.closed = true
+    C.ejdbqresultdispose(c.res)
+    if c.ownsQuery {
+        c.q.Del()
+    }
+}
+
 // Execute the query and only return the number of results it returned, not the results themselves
 func (q *EjQuery) Count(coll *EjColl) (int, *EjdbError) {
     var count C.uint32_t
@@ -0,0 +1,70 @@
+package goejdb
+
+// BeginTransactions begins a transaction on each of colls, in the order
+// given. If starting a transaction on any collection fails, every
+// transaction already begun is aborted before the error is returned.
+func (ejdb *Ejdb) BeginTransactions(colls ...*EjColl) *EjdbError {
+    for i, coll := range colls {
+        if err := coll.BeginTransaction(); err != nil {
+            for _, started := range colls[:i] {
+                started.AbortTransaction()
+            }
+            return err
+        }
+    }
+    return nil
+}
+
+// CommitTransactions commits the transaction on each of colls, in the order
+// given, returning the first error encountered, if any. It still attempts
+// to commit every collection even if an earlier one fails.
+func (ejdb *Ejdb) CommitTransactions(colls ...*EjColl) *EjdbError {
+    var first *EjdbError
+    for _, coll := range colls {
+        if err := coll.CommitTransaction(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// AbortTransactions aborts the transaction on each of colls, in the order
+// given, returning the first error encountered, if any. It still attempts
+// to abort every collection even if an earlier one fails.
+func (ejdb *Ejdb) AbortTransactions(colls ...*EjColl) *EjdbError {
+    var first *EjdbError
+    for _, coll := range colls {
+        if err := coll.AbortTransaction(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// WithTransaction begins a transaction on each of colls, invokes fn, and
+// commits every collection if fn returns nil or aborts every collection
+// otherwise. A panic inside fn also aborts every collection before
+// propagating, so callers never leave a collection stuck mid-transaction.
+func (ejdb *Ejdb) WithTransaction(colls []*EjColl, fn func() error) error {
+    if err := ejdb.BeginTransactions(colls...); err != nil {
+        return err
+    }
+
+    ranFn := false
+    defer func() {
+        if !ranFn {
+            ejdb.AbortTransactions(colls...)
+        }
+    }()
+
+    err := fn()
+    ranFn = true
+    if err != nil {
+        ejdb.AbortTransactions(colls...)
+        return err
+    }
+    if cerr := ejdb.CommitTransactions(colls...); cerr != nil {
+        return cerr
+    }
+    return nil
+}
@@ -0,0 +1,90 @@
+package goejdb
+
+import (
+    "errors"
+    "os"
+    "testing"
+)
+
+const txTestDbPath = "/tmp/goejdb_tx_test.db"
+
+func openTxTestColls(t *testing.T) (*Ejdb, *EjColl, *EjColl) {
+    os.Remove(txTestDbPath)
+    ejdb, err := Open(txTestDbPath, JBOWRITER|JBOCREAT|JBOTRUNC)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    a, err := ejdb.CreateColl("tx_a", nil)
+    if err != nil {
+        ejdb.Close()
+        t.Fatalf("CreateColl tx_a: %v", err)
+    }
+    b, err := ejdb.CreateColl("tx_b", nil)
+    if err != nil {
+        ejdb.Close()
+        t.Fatalf("CreateColl tx_b: %v", err)
+    }
+    return ejdb, a, b
+}
+
+func TestWithTransactionCommitsOnSuccess(t *testing.T) {
+    ejdb, a, b := openTxTestColls(t)
+    defer os.Remove(txTestDbPath)
+    defer ejdb.Close()
+
+    err := ejdb.WithTransaction([]*EjColl{a, b}, func() error {
+        if _, serr := a.SaveJson(`{"k":"a"}`); serr != nil {
+            return serr
+        }
+        if _, serr := b.SaveJson(`{"k":"b"}`); serr != nil {
+            return serr
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("WithTransaction: %v", err)
+    }
+
+    rows, ferr := a.Find(`{}`)
+    if ferr != nil {
+        t.Fatalf("Find a: %v", ferr)
+    }
+    if len(rows) != 1 {
+        t.Fatalf("expected 1 row in tx_a, got %d", len(rows))
+    }
+    rows, ferr = b.Find(`{}`)
+    if ferr != nil {
+        t.Fatalf("Find b: %v", ferr)
+    }
+    if len(rows) != 1 {
+        t.Fatalf("expected 1 row in tx_b, got %d", len(rows))
+    }
+}
+
+// TestWithTransactionAbortsAllOnError guards against a partial commit: if fn
+// fails after writing to one collection but before the other, neither write
+// should be visible afterwards.
+func TestWithTransactionAbortsAllOnError(t *testing.T) {
+    ejdb, a, b := openTxTestColls(t)
+    defer os.Remove(txTestDbPath)
+    defer ejdb.Close()
+
+    wantErr := errors.New("boom")
+    err := ejdb.WithTransaction([]*EjColl{a, b}, func() error {
+        if _, serr := a.SaveJson(`{"k":"a"}`); serr != nil {
+            return serr
+        }
+        return wantErr
+    })
+    if err != wantErr {
+        t.Fatalf("expected WithTransaction to propagate fn's error, got %v", err)
+    }
+
+    rows, ferr := a.Find(`{}`)
+    if ferr != nil {
+        t.Fatalf("Find a: %v", ferr)
+    }
+    if len(rows) != 0 {
+        t.Fatalf("expected tx_a's write to be rolled back, got %d rows", len(rows))
+    }
+}
@@ -0,0 +1,284 @@
+// Package qb is a fluent, typed builder for the JSON query/hints pairs that
+// Ejdb.CreateQuery expects (see the docstring there for the full list of
+// supported operators). It exists so callers who already hold bson.M or
+// map[string]interface{} values, for instance from "labix.org/v2/mgo/bson",
+// don't have to hand-build and double-encode JSON strings.
+package qb
+
+import (
+    "encoding/json"
+    "reflect"
+)
+
+// A Query accumulates match/update conditions and result hints and
+// serialises them to the JSON forms used by EjColl.FindQ / EjColl.UpdateQ.
+type Query struct {
+    cond  map[string]interface{}
+    hints map[string]interface{}
+}
+
+// New creates an empty Query ready to be extended with the builder methods.
+func New() *Query {
+    return &Query{cond: map[string]interface{}{}, hints: map[string]interface{}{}}
+}
+
+// toValue unwraps a nested *Query (as used by ElemMatch) to its condition
+// map; any other value, including bson.M and map[string]interface{}, is
+// passed through as-is since both already serialise the way EJDB expects.
+func toValue(v interface{}) interface{} {
+    if sub, ok := v.(*Query); ok {
+        return sub.cond
+    }
+    return v
+}
+
+// toSlice turns any slice value, e.g. []string{"a","b"}, into []interface{}
+// so it can be embedded in the condition map regardless of element type.
+func toSlice(v interface{}) []interface{} {
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Slice {
+        return []interface{}{v}
+    }
+    out := make([]interface{}, rv.Len())
+    for i := 0; i < rv.Len(); i++ {
+        out[i] = rv.Index(i).Interface()
+    }
+    return out
+}
+
+// setOp merges {op: val} into the condition object for field, e.g. chaining
+// Gt("age", 30) and Lt("age", 40) produces {"age": {"$gt":30, "$lt":40}}.
+func (q *Query) setOp(field, op string, val interface{}) *Query {
+    existing, ok := q.cond[field].(map[string]interface{})
+    if !ok {
+        existing = map[string]interface{}{}
+    }
+    existing[op] = toValue(val)
+    q.cond[field] = existing
+    return q
+}
+
+// setTop merges {field: val} into the top-level operator object named op,
+// e.g. Set("name", "Bruce") produces {"$set": {"name": "Bruce"}}.
+func (q *Query) setTop(op, field string, val interface{}) *Query {
+    existing, ok := q.cond[op].(map[string]interface{})
+    if !ok {
+        existing = map[string]interface{}{}
+    }
+    existing[field] = toValue(val)
+    q.cond[op] = existing
+    return q
+}
+
+// Eq matches field against a simple String, Number or Array value.
+func (q *Query) Eq(field string, val interface{}) *Query {
+    q.cond[field] = toValue(val)
+    return q
+}
+
+// Not negates val, which may be a plain value or an operator object such as
+// map[string]interface{}{"$begin": prefix}. A *Query is embedded as its whole
+// condition object, field names included.
+func (q *Query) Not(field string, val interface{}) *Query {
+    q.cond[field] = map[string]interface{}{"$not": toValue(val)}
+    return q
+}
+
+// Begin matches String fields starting with prefix.
+func (q *Query) Begin(field, prefix string) *Query {
+    return q.setOp(field, "$begin", prefix)
+}
+
+// Gt matches Number fields greater than val.
+func (q *Query) Gt(field string, val interface{}) *Query {
+    return q.setOp(field, "$gt", val)
+}
+
+// Gte matches Number fields greater than or equal to val.
+func (q *Query) Gte(field string, val interface{}) *Query {
+    return q.setOp(field, "$gte", val)
+}
+
+// Lt matches Number fields less than val.
+func (q *Query) Lt(field string, val interface{}) *Query {
+    return q.setOp(field, "$lt", val)
+}
+
+// Lte matches Number fields less than or equal to val.
+func (q *Query) Lte(field string, val interface{}) *Query {
+    return q.setOp(field, "$lte", val)
+}
+
+// Bt matches Number fields between lo and hi, inclusive.
+func (q *Query) Bt(field string, lo, hi interface{}) *Query {
+    return q.setOp(field, "$bt", []interface{}{lo, hi})
+}
+
+// In matches when field's String, Number or Array value is one of vals.
+func (q *Query) In(field string, vals interface{}) *Query {
+    return q.setOp(field, "$in", toSlice(vals))
+}
+
+// Nin matches when field's value is none of vals.
+func (q *Query) Nin(field string, vals interface{}) *Query {
+    return q.setOp(field, "$nin", toSlice(vals))
+}
+
+// Strand matches when all of vals are present among field's String tokens.
+func (q *Query) Strand(field string, vals interface{}) *Query {
+    return q.setOp(field, "$strand", toSlice(vals))
+}
+
+// Stror matches when any of vals are present among field's String tokens.
+func (q *Query) Stror(field string, vals interface{}) *Query {
+    return q.setOp(field, "$stror", toSlice(vals))
+}
+
+// Exists matches on whether field is present, per the exists flag.
+func (q *Query) Exists(field string, exists bool) *Query {
+    return q.setOp(field, "$exists", exists)
+}
+
+// Icase matches val against field case-insensitively. val may be a plain
+// value or an operator object, e.g. Icase("name", map[string]interface{}{"$in": vals}).
+// A *Query is embedded as its whole condition object, field names included.
+func (q *Query) Icase(field string, val interface{}) *Query {
+    q.cond[field] = map[string]interface{}{"$icase": toValue(val)}
+    return q
+}
+
+// ElemMatch matches array fields having an element satisfying sub. Only one
+// ElemMatch is allowed per array field.
+func (q *Query) ElemMatch(field string, sub *Query) *Query {
+    q.cond[field] = map[string]interface{}{"$elemMatch": sub.cond}
+    return q
+}
+
+// Set assigns val to field on matching records.
+func (q *Query) Set(field string, val interface{}) *Query {
+    return q.setTop("$set", field, val)
+}
+
+// Upsert assigns val to field, inserting a new record from the update's
+// fields if no record matches.
+func (q *Query) Upsert(field string, val interface{}) *Query {
+    return q.setTop("$upsert", field, val)
+}
+
+// Inc adds n, a Number, to field on matching records.
+func (q *Query) Inc(field string, n interface{}) *Query {
+    return q.setTop("$inc", field, n)
+}
+
+// AddToSet appends val to field if not already present, creating field as an
+// array if missing.
+func (q *Query) AddToSet(field string, val interface{}) *Query {
+    return q.setTop("$addToSet", field, val)
+}
+
+// AddToSetAll is the batch form of AddToSet.
+func (q *Query) AddToSetAll(field string, vals interface{}) *Query {
+    return q.setTop("$addToSetAll", field, toSlice(vals))
+}
+
+// Pull removes every occurrence of val from the array field.
+func (q *Query) Pull(field string, val interface{}) *Query {
+    return q.setTop("$pull", field, val)
+}
+
+// PullAll is the batch form of Pull.
+func (q *Query) PullAll(field string, vals interface{}) *Query {
+    return q.setTop("$pullAll", field, toSlice(vals))
+}
+
+// Push appends val to field, creating field as an array if missing.
+func (q *Query) Push(field string, val interface{}) *Query {
+    return q.setTop("$push", field, val)
+}
+
+// PushAll is the batch form of Push.
+func (q *Query) PushAll(field string, vals interface{}) *Query {
+    return q.setTop("$pushAll", field, toSlice(vals))
+}
+
+// Dropall removes matching records in place.
+func (q *Query) Dropall() *Query {
+    q.cond["$dropall"] = true
+    return q
+}
+
+// Join resolves the OID(s) held by field against collection, mirroring the
+// `$do : {fpath : {$join : 'collectionname'}}` query form.
+func (q *Query) Join(field, collection string) *Query {
+    do, ok := q.cond["$do"].(map[string]interface{})
+    if !ok {
+        do = map[string]interface{}{}
+    }
+    do[field] = map[string]interface{}{"$join": collection}
+    q.cond["$do"] = do
+    return q
+}
+
+// OrderBy sorts results by field, ascending if dir is positive and
+// descending otherwise. Calling it more than once adds further sort fields;
+// Hints serialises them sorted by field name, so their priority follows
+// field-name order, not the order of the calls.
+func (q *Query) OrderBy(field string, dir int) *Query {
+    orderby, ok := q.hints["$orderby"].(map[string]interface{})
+    if !ok {
+        orderby = map[string]interface{}{}
+    }
+    if dir < 0 {
+        orderby[field] = -1
+    } else {
+        orderby[field] = 1
+    }
+    q.hints["$orderby"] = orderby
+    return q
+}
+
+// Fields restricts the fetched fields to those named, plus _id.
+func (q *Query) Fields(fields ...string) *Query {
+    set, ok := q.hints["$fields"].(map[string]interface{})
+    if !ok {
+        set = map[string]interface{}{}
+    }
+    for _, f := range fields {
+        set[f] = 1
+    }
+    q.hints["$fields"] = set
+    return q
+}
+
+// Skip sets the number of matching records to skip before the first result.
+func (q *Query) Skip(n int) *Query {
+    q.hints["$skip"] = n
+    return q
+}
+
+// Max sets the maximum number of records in the result set.
+func (q *Query) Max(n int) *Query {
+    q.hints["$max"] = n
+    return q
+}
+
+// Query serialises the accumulated match/update conditions to the JSON
+// string form expected by Ejdb.CreateQuery / EjColl.Update.
+func (q *Query) Query() string {
+    b, err := json.Marshal(q.cond)
+    if err != nil {
+        return "{}"
+    }
+    return string(b)
+}
+
+// Hints serialises the accumulated $orderby/$fields/$skip/$max hints to the
+// JSON string form expected by EjQuery.SetHints. It is "{}" if no hints were
+// set.
+func (q *Query) Hints() string {
+    b, err := json.Marshal(q.hints)
+    if err != nil {
+        return "{}"
+    }
+    return string(b)
+}
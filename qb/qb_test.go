@@ -0,0 +1,112 @@
+package qb
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func decode(t *testing.T, s string) map[string]interface{} {
+    var m map[string]interface{}
+    if err := json.Unmarshal([]byte(s), &m); err != nil {
+        t.Fatalf("decode %q: %v", s, err)
+    }
+    return m
+}
+
+func TestQueryChainsOperatorsOnSameField(t *testing.T) {
+    q := New().Gt("age", 30).Lt("age", 40)
+    got := decode(t, q.Query())
+    age, ok := got["age"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("expected age to be an operator object, got %v", got["age"])
+    }
+    if age["$gt"] != float64(30) || age["$lt"] != float64(40) {
+        t.Fatalf("expected both $gt and $lt to be present, got %v", age)
+    }
+}
+
+func TestQueryInAcceptsSliceAndSingleValue(t *testing.T) {
+    got := decode(t, New().In("name", []string{"a", "b"}).Query())
+    name, ok := got["name"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("expected name to be an operator object, got %v", got["name"])
+    }
+    in, ok := name["$in"].([]interface{})
+    if !ok || len(in) != 2 {
+        t.Fatalf("expected $in to hold 2 values, got %v", name["$in"])
+    }
+
+    got = decode(t, New().In("name", "a").Query())
+    name, ok = got["name"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("expected name to be an operator object, got %v", got["name"])
+    }
+    in, ok = name["$in"].([]interface{})
+    if !ok || len(in) != 1 || in[0] != "a" {
+        t.Fatalf("expected a single value to be wrapped as a 1-element $in, got %v", name["$in"])
+    }
+}
+
+func TestQueryElemMatchNestsSubquery(t *testing.T) {
+    sub := New().Eq("k", "v")
+    got := decode(t, New().ElemMatch("items", sub).Query())
+    items, ok := got["items"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("expected items to be an operator object, got %v", got["items"])
+    }
+    elem, ok := items["$elemMatch"].(map[string]interface{})
+    if !ok || elem["k"] != "v" {
+        t.Fatalf("expected $elemMatch to carry the subquery's condition, got %v", items["$elemMatch"])
+    }
+}
+
+func TestQuerySetAndPushShareUpdateObject(t *testing.T) {
+    q := New().Set("name", "Wayne").Push("tags", "x")
+    got := decode(t, q.Query())
+    set, ok := got["$set"].(map[string]interface{})
+    if !ok || set["name"] != "Wayne" {
+        t.Fatalf("expected $set.name == Wayne, got %v", got["$set"])
+    }
+    push, ok := got["$push"].(map[string]interface{})
+    if !ok || push["tags"] != "x" {
+        t.Fatalf("expected $push.tags == x, got %v", got["$push"])
+    }
+}
+
+func TestQueryJoinBuildsDoClause(t *testing.T) {
+    got := decode(t, New().Join("owner.id", "users").Query())
+    do, ok := got["$do"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("expected a $do clause, got %v", got["$do"])
+    }
+    field, ok := do["owner.id"].(map[string]interface{})
+    if !ok || field["$join"] != "users" {
+        t.Fatalf("expected owner.id to join users, got %v", do["owner.id"])
+    }
+}
+
+func TestQueryHintsDefaultToEmptyObject(t *testing.T) {
+    if got := New().Query(); got != `{}` {
+        t.Fatalf("expected empty query to serialise to {}, got %s", got)
+    }
+    if got := New().Hints(); got != `{}` {
+        t.Fatalf("expected empty hints to serialise to {}, got %s", got)
+    }
+}
+
+func TestQueryHintsAccumulate(t *testing.T) {
+    q := New().OrderBy("name", 1).OrderBy("age", -1).Fields("name", "age").Skip(5).Max(10)
+    got := decode(t, q.Hints())
+
+    orderby, ok := got["$orderby"].(map[string]interface{})
+    if !ok || orderby["name"] != float64(1) || orderby["age"] != float64(-1) {
+        t.Fatalf("expected $orderby to carry both fields, got %v", got["$orderby"])
+    }
+    fields, ok := got["$fields"].(map[string]interface{})
+    if !ok || fields["name"] != float64(1) || fields["age"] != float64(1) {
+        t.Fatalf("expected $fields to carry both fields, got %v", got["$fields"])
+    }
+    if got["$skip"] != float64(5) || got["$max"] != float64(10) {
+        t.Fatalf("expected $skip/$max to be set, got %v", got)
+    }
+}